@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"photography-assistant/backend/storage"
+)
+
+// defaultListLimit is how many analyses GET /analyses returns when the caller doesn't pass
+// a limit.
+const defaultListLimit = 20
+
+// analysisDetailResponse is what both GET /analyses/:id and GET /analyses return per
+// analysis: the stored model output plus a presigned URL for the image it was run on.
+type analysisDetailResponse struct {
+	ID        string          `json:"id"`
+	Model     string          `json:"model"`
+	CreatedAt time.Time       `json:"createdAt"`
+	ImageURL  string          `json:"imageUrl"`
+	Analysis  json.RawMessage `json:"analysis"`
+}
+
+func getAnalysisHandler(c *gin.Context) {
+	id := c.Param("id")
+
+	rec, err := analysisStore.Get(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Analysis not found"})
+			return
+		}
+		log.Printf("Error fetching analysis %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch analysis"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toAnalysisDetail(c, rec))
+}
+
+func listAnalysesHandler(c *gin.Context) {
+	limit := defaultListLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+
+	page, err := analysisStore.List(c.Request.Context(), limit, c.Query("cursor"))
+	if err != nil {
+		log.Printf("Error listing analyses: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list analyses"})
+		return
+	}
+
+	items := make([]analysisDetailResponse, 0, len(page.Records))
+	for _, rec := range page.Records {
+		items = append(items, toAnalysisDetail(c, rec))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"analyses":   items,
+		"nextCursor": page.NextCursor,
+	})
+}
+
+func toAnalysisDetail(c *gin.Context, rec *storage.Record) analysisDetailResponse {
+	imageURL, err := analysisStore.ImageURL(c.Request.Context(), rec)
+	if err != nil {
+		log.Printf("Error presigning image url for %s: %v", rec.ID, err)
+	}
+
+	return analysisDetailResponse{
+		ID:        rec.ID,
+		Model:     rec.Model,
+		CreatedAt: rec.CreatedAt,
+		ImageURL:  imageURL,
+		Analysis:  rec.Analysis,
+	}
+}
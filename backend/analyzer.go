@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultModelEnvVar names the environment variable that picks the analyzer used when a
+// request doesn't specify one via the X-Model header or model query param.
+const defaultModelEnvVar = "DEFAULT_MODEL"
+
+// anthropicBedrockVersion is the Bedrock-specific anthropic_version required by the Claude 3
+// InvokeModel payload, as opposed to the public Anthropic API version string.
+const anthropicBedrockVersion = "bedrock-2023-05-31"
+
+// Analyzer is implemented by every model backend that can turn a photo into an
+// AnalysisResponse. New models are added by registering a new entry in analyzerRegistry,
+// not by touching analyzeImageHandler.
+type Analyzer interface {
+	Analyze(ctx context.Context, imageBytes []byte, mimeType string) (*AnalysisResponse, *TokenUsage, error)
+}
+
+// StreamingAnalyzer is implemented by the Analyzers that can relay their response
+// incrementally over SSE instead of waiting for the full result. Only llamaAnalyzer
+// implements it today - Claude 3 goes through Bedrock's InvokeModel API, which has no
+// streaming variant in this codebase, so /analyze/stream rejects a Claude 3 selection with
+// a clear error instead of silently ignoring it.
+//
+// AnalyzeStream relies on parseAnalysisJSON's brace-index scraping rather than the
+// forced-tool-use path Analyze tries first: record_analysis's tool-use input arrives as
+// incremental JSON deltas on the tool call itself, not as text deltas, so
+// emitCompletedFields's text-buffer parsing doesn't apply to it. A malformed streamed
+// response can therefore still fail to parse.
+//
+// AnalyzeStream returns the token usage reported for the call (nil if the provider didn't
+// report one) alongside the error, so callers can log spend the same way Analyze's callers
+// do.
+type StreamingAnalyzer interface {
+	AnalyzeStream(ctx context.Context, imageBytes []byte, w http.ResponseWriter) (*TokenUsage, error)
+}
+
+// TokenUsage is the estimated Bedrock token cost of one Analyze call, reported by the
+// provider when it's available, so /metrics can track spend per model.
+type TokenUsage struct {
+	InputTokens  int
+	OutputTokens int
+}
+
+// analyzerConfig holds the per-provider defaults that used to be hardcoded inline in
+// analyzeImageWithBedrock.
+type analyzerConfig struct {
+	ModelID     string
+	MaxTokens   int32
+	Temperature float32
+	TopP        float32
+}
+
+// analyzerRegistry maps the model name selectable via the X-Model header or model query
+// param to the Analyzer that serves it.
+var analyzerRegistry = map[string]Analyzer{
+	"llama-4-scout": &llamaAnalyzer{cfg: analyzerConfig{
+		ModelID:     "us.meta.llama4-scout-17b-instruct-v1:0",
+		MaxTokens:   3000, // headroom for the record_analysis tool-use payload over plain text
+		Temperature: 0.7,
+		TopP:        0.9,
+	}},
+	"claude-3-haiku": &claude3Analyzer{cfg: analyzerConfig{
+		ModelID:     "anthropic.claude-3-haiku-20240307-v1:0",
+		MaxTokens:   3000, // headroom for the record_analysis tool-use payload over plain text
+		Temperature: 0.7,
+		TopP:        0.9,
+	}},
+	"claude-3-sonnet": &claude3Analyzer{cfg: analyzerConfig{
+		ModelID:     "anthropic.claude-3-sonnet-20240229-v1:0",
+		MaxTokens:   3000,
+		Temperature: 0.7,
+		TopP:        0.9,
+	}},
+	"claude-3-opus": &claude3Analyzer{cfg: analyzerConfig{
+		ModelID:     "anthropic.claude-3-opus-20240229-v1:0",
+		MaxTokens:   3000,
+		Temperature: 0.7,
+		TopP:        0.9,
+	}},
+}
+
+const defaultAnalyzerName = "llama-4-scout"
+
+// resolveAnalyzer picks the Analyzer for a request: the X-Model header takes precedence
+// over the model query param, which takes precedence over the DEFAULT_MODEL env var, which
+// falls back to the Llama 4 Scout model this service originally shipped with.
+func resolveAnalyzer(c *gin.Context) (Analyzer, string, error) {
+	name := c.GetHeader("X-Model")
+	if name == "" {
+		name = c.Query("model")
+	}
+	if name == "" {
+		name = os.Getenv(defaultModelEnvVar)
+	}
+	if name == "" {
+		name = defaultAnalyzerName
+	}
+
+	analyzer, ok := analyzerRegistry[name]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown model %q", name)
+	}
+	return analyzer, name, nil
+}
+
+// llamaAnalyzer calls Bedrock's Converse API, the path this service used before multiple
+// providers were supported.
+type llamaAnalyzer struct {
+	cfg analyzerConfig
+}
+
+func (a *llamaAnalyzer) Analyze(ctx context.Context, imageBytes []byte, mimeType string) (*AnalysisResponse, *TokenUsage, error) {
+	userMessage := types.Message{
+		Role: types.ConversationRoleUser,
+		Content: []types.ContentBlock{
+			&types.ContentBlockMemberImage{
+				Value: types.ImageBlock{
+					Format: types.ImageFormatJpeg,
+					Source: &types.ImageSourceMemberBytes{
+						Value: imageBytes,
+					},
+				},
+			},
+			&types.ContentBlockMemberText{
+				Value: photographyAnalysisPrompt,
+			},
+		},
+	}
+
+	input := &bedrockruntime.ConverseInput{
+		ModelId:  aws.String(a.cfg.ModelID),
+		Messages: []types.Message{userMessage},
+		InferenceConfig: &types.InferenceConfiguration{
+			MaxTokens:   aws.Int32(a.cfg.MaxTokens),
+			Temperature: aws.Float32(a.cfg.Temperature),
+			TopP:        aws.Float32(a.cfg.TopP),
+		},
+		ToolConfig: recordAnalysisToolConfig(),
+	}
+
+	result, err := bedrockClient.Converse(ctx, input)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to call bedrock converse: %w", err)
+	}
+
+	usage := converseTokenUsage(result.Usage)
+
+	if result.Output == nil {
+		return nil, usage, fmt.Errorf("no output in bedrock response")
+	}
+
+	msgResult, ok := result.Output.(*types.ConverseOutputMemberMessage)
+	if !ok {
+		return nil, usage, fmt.Errorf("no message in bedrock response")
+	}
+
+	if analysis, err := analysisFromToolUse(msgResult.Value.Content); err == nil {
+		return analysis, usage, nil
+	}
+
+	// The model didn't emit the forced tool call (e.g. refused, or fell back to plain
+	// text) - fall back to the old free-text JSON parsing so a response still comes back.
+	for _, block := range msgResult.Value.Content {
+		if textBlock, ok := block.(*types.ContentBlockMemberText); ok {
+			analysis, err := parseAnalysisJSON(textBlock.Value)
+			return analysis, usage, err
+		}
+	}
+
+	return nil, usage, fmt.Errorf("no tool use or text content in bedrock response")
+}
+
+// AnalyzeStream calls Bedrock's ConverseStream API and relays the assistant's text deltas
+// to w as Server-Sent Events, one event per AnalysisResponse field as soon as that field's
+// value closes in the buffered JSON. Once the stream ends it always validates the fully
+// aggregated text against AnalysisResponse and sends the result as a final "done" event,
+// whether or not any per-field events were sent, so existing consumers of the final payload
+// still get a correct result.
+func (a *llamaAnalyzer) AnalyzeStream(ctx context.Context, imageBytes []byte, w http.ResponseWriter) (*TokenUsage, error) {
+	userMessage := types.Message{
+		Role: types.ConversationRoleUser,
+		Content: []types.ContentBlock{
+			&types.ContentBlockMemberImage{
+				Value: types.ImageBlock{
+					Format: types.ImageFormatJpeg,
+					Source: &types.ImageSourceMemberBytes{
+						Value: imageBytes,
+					},
+				},
+			},
+			&types.ContentBlockMemberText{
+				Value: photographyAnalysisPrompt,
+			},
+		},
+	}
+
+	input := &bedrockruntime.ConverseStreamInput{
+		ModelId:  aws.String(a.cfg.ModelID),
+		Messages: []types.Message{userMessage},
+		InferenceConfig: &types.InferenceConfiguration{
+			MaxTokens:   aws.Int32(a.cfg.MaxTokens),
+			Temperature: aws.Float32(a.cfg.Temperature),
+			TopP:        aws.Float32(a.cfg.TopP),
+		},
+	}
+
+	result, err := bedrockClient.ConverseStream(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call bedrock converse stream: %w", err)
+	}
+
+	stream := result.GetStream()
+	defer stream.Close()
+
+	var buffer strings.Builder
+	emitted := map[string]bool{}
+	var usage *TokenUsage
+
+	for event := range stream.Events() {
+		switch e := event.(type) {
+		case *types.ConverseStreamOutputMemberContentBlockDelta:
+			textDelta, ok := e.Value.Delta.(*types.ContentBlockDeltaMemberText)
+			if !ok {
+				continue
+			}
+			buffer.WriteString(textDelta.Value)
+			emitCompletedFields(buffer.String(), emitted, w)
+		case *types.ConverseStreamOutputMemberMetadata:
+			usage = converseTokenUsage(e.Value.Usage)
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		return usage, fmt.Errorf("bedrock stream error: %w", err)
+	}
+
+	analysis, err := parseAnalysisJSON(buffer.String())
+	if err != nil {
+		return usage, err
+	}
+
+	writeSSEEvent(w, "done", analysis)
+	return usage, nil
+}
+
+// converseTokenUsage converts Bedrock's Converse token usage into our provider-agnostic
+// TokenUsage, returning nil when usage wasn't reported.
+func converseTokenUsage(usage *types.TokenUsage) *TokenUsage {
+	if usage == nil {
+		return nil
+	}
+	return &TokenUsage{
+		InputTokens:  int(aws.ToInt32(usage.InputTokens)),
+		OutputTokens: int(aws.ToInt32(usage.OutputTokens)),
+	}
+}
+
+// claude3Analyzer calls Bedrock's InvokeModel API with Claude 3's native request format.
+// Claude 3 is not reachable through Converse in the same ContentBlock shape the Llama path
+// uses here, so it builds the anthropic_version + messages payload directly.
+type claude3Analyzer struct {
+	cfg analyzerConfig
+}
+
+// claude3Request mirrors the Bedrock InvokeModel body Claude 3 models expect. Tools and
+// ToolChoice force the same record_analysis call the Converse path uses via
+// recordAnalysisToolConfig, so Claude 3 doesn't need the free-text JSON scraping in
+// parseAnalysisJSON either.
+type claude3Request struct {
+	AnthropicVersion string             `json:"anthropic_version"`
+	MaxTokens        int32              `json:"max_tokens"`
+	Temperature      float32            `json:"temperature"`
+	TopP             float32            `json:"top_p"`
+	Messages         []claude3Message   `json:"messages"`
+	Tools            []claude3Tool      `json:"tools,omitempty"`
+	ToolChoice       *claude3ToolChoice `json:"tool_choice,omitempty"`
+}
+
+// claude3Tool mirrors the Messages API's tool definition shape, which unlike Converse's
+// ToolSpecification takes the input schema inline rather than wrapped in a document.
+type claude3Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+// claude3ToolChoice forces the model to call the named tool instead of replying with
+// free-form text, the Messages API equivalent of Converse's ToolChoiceMemberTool.
+type claude3ToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type claude3Message struct {
+	Role    string                `json:"role"`
+	Content []claude3ContentBlock `json:"content"`
+}
+
+type claude3ContentBlock struct {
+	Type   string              `json:"type"`
+	Text   string              `json:"text,omitempty"`
+	Source *claude3ImageSource `json:"source,omitempty"`
+}
+
+type claude3ImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+// claude3Response mirrors the subset of the Bedrock InvokeModel response body this analyzer
+// needs: the content blocks the model returned plus its token usage. A tool_use block
+// carries its record_analysis input in Input rather than Text.
+type claude3Response struct {
+	Content []struct {
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		Name  string          `json:"name"`
+		Input json.RawMessage `json:"input"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+func (a *claude3Analyzer) Analyze(ctx context.Context, imageBytes []byte, mimeType string) (*AnalysisResponse, *TokenUsage, error) {
+	reqBody := claude3Request{
+		AnthropicVersion: anthropicBedrockVersion,
+		MaxTokens:        a.cfg.MaxTokens,
+		Temperature:      a.cfg.Temperature,
+		TopP:             a.cfg.TopP,
+		Messages: []claude3Message{
+			{
+				Role: "user",
+				Content: []claude3ContentBlock{
+					{
+						Type: "image",
+						Source: &claude3ImageSource{
+							Type:      "base64",
+							MediaType: mimeType,
+							Data:      base64.StdEncoding.EncodeToString(imageBytes),
+						},
+					},
+					{
+						Type: "text",
+						Text: photographyAnalysisPrompt,
+					},
+				},
+			},
+		},
+		Tools: []claude3Tool{
+			{
+				Name:        recordAnalysisToolName,
+				Description: recordAnalysisDescription,
+				InputSchema: recordAnalysisInputSchema(),
+			},
+		},
+		ToolChoice: &claude3ToolChoice{Type: "tool", Name: recordAnalysisToolName},
+	}
+
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal claude 3 request: %w", err)
+	}
+
+	result, err := bedrockClient.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+		ModelId:     aws.String(a.cfg.ModelID),
+		ContentType: aws.String("application/json"),
+		Body:        payload,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to call bedrock invoke model: %w", err)
+	}
+
+	var claudeResp claude3Response
+	if err := json.Unmarshal(result.Body, &claudeResp); err != nil {
+		log.Printf("Failed to parse claude 3 response: %s", result.Body)
+		return nil, nil, fmt.Errorf("failed to parse claude 3 response: %w", err)
+	}
+
+	usage := &TokenUsage{InputTokens: claudeResp.Usage.InputTokens, OutputTokens: claudeResp.Usage.OutputTokens}
+
+	for _, block := range claudeResp.Content {
+		if block.Type != "tool_use" || block.Name != recordAnalysisToolName {
+			continue
+		}
+		var input recordAnalysisInput
+		if err := json.Unmarshal(block.Input, &input); err != nil {
+			return nil, usage, fmt.Errorf("failed to unmarshal claude 3 tool use input: %w", err)
+		}
+		return analysisFromRecordAnalysisInput(input), usage, nil
+	}
+
+	// The model didn't emit the forced tool call - fall back to the old free-text JSON
+	// parsing so a response still comes back.
+	for _, block := range claudeResp.Content {
+		if block.Type == "text" {
+			analysis, err := parseAnalysisJSON(block.Text)
+			return analysis, usage, err
+		}
+	}
+
+	return nil, usage, fmt.Errorf("no tool use or text content in claude 3 response")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+
+	"photography-assistant/backend/middleware"
+)
+
+// newKeyStore builds the API key store used by AuthMiddleware: a DynamoDB table when
+// API_KEYS_TABLE is set, otherwise the fixed list of keys in the comma-separated API_KEYS
+// env var.
+func newKeyStore() middleware.KeyStore {
+	if table := os.Getenv("API_KEYS_TABLE"); table != "" {
+		return middleware.NewDynamoKeyStore(awsConfig, table)
+	}
+
+	keys := strings.Split(os.Getenv("API_KEYS"), ",")
+	return middleware.NewEnvKeyStore(keys)
+}
+
+// defaultRateLimitRPS and defaultRateLimitBurst are used when RATE_LIMIT_RPS /
+// RATE_LIMIT_BURST aren't set - conservative enough to keep a single caller from running up
+// the Bedrock bill, generous enough not to bother normal usage.
+const (
+	defaultRateLimitRPS   = 1.0
+	defaultRateLimitBurst = 5
+)
+
+// newRateLimiter builds the per-key token-bucket limiter from RATE_LIMIT_RPS and
+// RATE_LIMIT_BURST.
+func newRateLimiter() *middleware.RateLimiter {
+	rps := envFloat("RATE_LIMIT_RPS", defaultRateLimitRPS)
+	burst := envInt("RATE_LIMIT_BURST", defaultRateLimitBurst)
+	return middleware.NewRateLimiter(rps, burst)
+}
+
+// defaultDailyQuota is the fallback daily per-key request quota when DAILY_QUOTA isn't set.
+const defaultDailyQuota = 100
+
+func dailyQuota() int64 {
+	return int64(envInt("DAILY_QUOTA", defaultDailyQuota))
+}
+
+// newQuotaCounter builds the daily quota counter: Redis-backed when REDIS_ADDR is set, so
+// quotas are shared across instances, or in-memory otherwise.
+func newQuotaCounter() middleware.QuotaCounter {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		log.Printf("REDIS_ADDR not set, using in-memory quota counter")
+		return middleware.NewMemoryQuotaCounter()
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	return middleware.NewRedisQuotaCounter(client)
+}
+
+func envFloat(name string, fallback float64) float64 {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		log.Printf("Invalid value for %s, using default %v: %v", name, fallback, err)
+		return fallback
+	}
+	return value
+}
+
+func envInt(name string, fallback int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Printf("Invalid value for %s, using default %v: %v", name, fallback, err)
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,224 @@
+// Package imageprep normalizes a user-uploaded photo before it's sent to Bedrock: it
+// sniffs the real format instead of trusting the caller, corrects EXIF rotation, downscales
+// to the size Claude's vision models recommend, and re-encodes to JPEG so metadata (and the
+// token cost of an oversized original) doesn't make it to the model.
+package imageprep
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/rwcarlsen/goexif/exif"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// MaxSourceBytes is the largest decoded upload this package will process. Anything larger
+// is rejected before it's decoded, mirroring the cap the tavern asset agent uses.
+const MaxSourceBytes = 5 * 1024 * 1024
+
+// MaxEdgePixels is the longest-edge target after downscaling, matching Claude's vision
+// guidance for image inputs.
+const MaxEdgePixels = 1568
+
+// JPEGQuality is the quality used when re-encoding the normalized image.
+const JPEGQuality = 85
+
+// ErrTooLarge is returned when the source image exceeds MaxSourceBytes.
+var ErrTooLarge = fmt.Errorf("image exceeds maximum allowed size of %d bytes", MaxSourceBytes)
+
+// ErrUnsupportedFormat is returned when the source bytes don't match a format this package
+// can decode.
+var ErrUnsupportedFormat = fmt.Errorf("unsupported image format")
+
+// Result is the output of Process: a re-encoded JPEG ready to hand to Bedrock.
+type Result struct {
+	Bytes  []byte
+	Format types.ImageFormat
+}
+
+// HEICDecoder decodes HEIC/HEIF bytes into an image.Image. HEIC support isn't in the
+// standard library or golang.org/x/image, so it's pluggable: a build that needs it can call
+// RegisterHEICDecoder from an init() backed by a cgo or WASM HEIC library.
+type HEICDecoder func(data []byte) (image.Image, error)
+
+var heicDecoder HEICDecoder
+
+// RegisterHEICDecoder installs the decoder used for HEIC sources. Until one is registered,
+// HEIC uploads are rejected with ErrUnsupportedFormat.
+func RegisterHEICDecoder(decoder HEICDecoder) {
+	heicDecoder = decoder
+}
+
+// Process sniffs the format of raw, decodes it, applies EXIF orientation, downscales the
+// longest edge to MaxEdgePixels, and re-encodes to JPEG at JPEGQuality, stripping whatever
+// metadata the source carried in the process.
+func Process(raw []byte) (*Result, error) {
+	if len(raw) > MaxSourceBytes {
+		return nil, ErrTooLarge
+	}
+
+	img, err := decode(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	img = applyEXIFOrientation(img, raw)
+	img = downscale(img, MaxEdgePixels)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: JPEGQuality}); err != nil {
+		return nil, fmt.Errorf("failed to re-encode image: %w", err)
+	}
+
+	return &Result{Bytes: buf.Bytes(), Format: types.ImageFormatJpeg}, nil
+}
+
+// decode sniffs the format from magic bytes and decodes with the matching codec. JPEG and
+// PNG go through the standard library, WebP through golang.org/x/image/webp, and HEIC
+// through the pluggable heicDecoder if one has been registered.
+func decode(raw []byte) (image.Image, error) {
+	switch {
+	case isJPEG(raw), isPNG(raw):
+		img, _, err := image.Decode(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+		return img, nil
+	case isWebP(raw):
+		img, err := webp.Decode(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode webp image: %w", err)
+		}
+		return img, nil
+	case isHEIC(raw):
+		if heicDecoder == nil {
+			return nil, fmt.Errorf("%w: heic support requires a registered decoder", ErrUnsupportedFormat)
+		}
+		img, err := heicDecoder(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode heic image: %w", err)
+		}
+		return img, nil
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+func isJPEG(raw []byte) bool {
+	return len(raw) >= 3 && raw[0] == 0xFF && raw[1] == 0xD8 && raw[2] == 0xFF
+}
+
+func isPNG(raw []byte) bool {
+	pngMagic := []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+	return len(raw) >= len(pngMagic) && bytes.Equal(raw[:len(pngMagic)], pngMagic)
+}
+
+func isWebP(raw []byte) bool {
+	return len(raw) >= 12 && bytes.Equal(raw[0:4], []byte("RIFF")) && bytes.Equal(raw[8:12], []byte("WEBP"))
+}
+
+func isHEIC(raw []byte) bool {
+	if len(raw) < 12 || !bytes.Equal(raw[4:8], []byte("ftyp")) {
+		return false
+	}
+	brand := string(raw[8:12])
+	switch brand {
+	case "heic", "heix", "hevc", "heim", "heis", "mif1", "msf1":
+		return true
+	default:
+		return false
+	}
+}
+
+// applyEXIFOrientation reads the EXIF orientation tag (if present) from the original bytes
+// and rotates img for orientations 3, 6 and 8 so it displays upright. Images without a
+// recognizable EXIF block, with an orientation of 1 ("normal"), or with one of the mirrored
+// orientations (2, 4, 5, 7) are returned unchanged; nothing is flipped.
+func applyEXIFOrientation(img image.Image, raw []byte) image.Image {
+	x, err := exif.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return img
+	}
+
+	tag, err := x.Get(exif.Orientation)
+	if err != nil {
+		return img
+	}
+
+	orientation, err := tag.Int(0)
+	if err != nil {
+		return img
+	}
+
+	switch orientation {
+	case 3:
+		return rotate180(img)
+	case 6:
+		return rotate90(img)
+	case 8:
+		return rotate270(img)
+	default:
+		return img
+	}
+}
+
+func rotate90(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.Set(b.Max.Y-1-y, x, img.At(x, y))
+		}
+	}
+	return dst
+}
+
+func rotate180(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.Set(b.Max.X-1-x, b.Max.Y-1-y, img.At(x, y))
+		}
+	}
+	return dst
+}
+
+func rotate270(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.Set(y, b.Max.X-1-x, img.At(x, y))
+		}
+	}
+	return dst
+}
+
+// downscale shrinks img so its longest edge is at most maxEdge, preserving aspect ratio.
+// Images already within the limit are returned unchanged.
+func downscale(img image.Image, maxEdge int) image.Image {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+
+	longest := width
+	if height > longest {
+		longest = height
+	}
+	if longest <= maxEdge {
+		return img
+	}
+
+	scale := float64(maxEdge) / float64(longest)
+	newWidth := int(float64(width) * scale)
+	newHeight := int(float64(height) * scale)
+
+	dst := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+	return dst
+}
@@ -4,20 +4,30 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+
+	"photography-assistant/backend/imageprep"
+	"photography-assistant/backend/metrics"
+	"photography-assistant/backend/middleware"
+	"photography-assistant/backend/storage"
 )
 
+// dedupWindow is how long an identical upload reuses a prior analysis instead of paying
+// for another Bedrock call.
+const dedupWindow = 24 * time.Hour
+
 // Request structure matching frontend
 type AnalysisRequest struct {
 	Image string `json:"image"` // base64 encoded image
@@ -34,6 +44,8 @@ type AnalysisResponse struct {
 }
 
 var bedrockClient *bedrockruntime.Client
+var analysisStore storage.Store
+var awsConfig aws.Config
 
 func init() {
 	// Load AWS configuration
@@ -42,7 +54,29 @@ func init() {
 		log.Fatalf("Failed to load AWS config: %v", err)
 	}
 
+	awsConfig = cfg
 	bedrockClient = bedrockruntime.NewFromConfig(cfg)
+	analysisStore = newAnalysisStore(cfg)
+}
+
+// newAnalysisStore returns the DynamoDB/S3-backed Store when ANALYSES_TABLE and
+// ANALYSES_BUCKET are configured, or an in-memory Store for local development otherwise.
+func newAnalysisStore(cfg aws.Config) storage.Store {
+	table := os.Getenv("ANALYSES_TABLE")
+	bucket := os.Getenv("ANALYSES_BUCKET")
+	if table == "" || bucket == "" {
+		log.Printf("ANALYSES_TABLE/ANALYSES_BUCKET not set, using in-memory analysis store")
+		return storage.NewMemoryStore()
+	}
+
+	return storage.NewDynamoS3Store(cfg, table, bucket)
+}
+
+// analyzeResponse is the POST /analyze response: the analysis plus the ID it was stored
+// under, so the client can fetch it again later via GET /analyses/:id.
+type analyzeResponse struct {
+	*AnalysisResponse
+	ID string `json:"id"`
 }
 
 func analyzeImageHandler(c *gin.Context) {
@@ -69,27 +103,173 @@ func analyzeImageHandler(c *gin.Context) {
 	}
 
 	// Validate base64 data
-	if _, err := base64.StdEncoding.DecodeString(imageData); err != nil {
+	rawBytes, err := base64.StdEncoding.DecodeString(imageData)
+	if err != nil {
 		log.Printf("Error decoding base64 image: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image data"})
 		return
 	}
 
-	// Analyze image with Bedrock
-	analysis, err := analyzeImageWithBedrock(c.Request.Context(), imageData)
+	prepared, err := imageprep.Process(rawBytes)
+	if err != nil {
+		if errors.Is(err, imageprep.ErrTooLarge) {
+			metrics.RecordError("image_too_large")
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error preparing image: %v", err)
+		metrics.RecordError("invalid_image")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or unsupported image"})
+		return
+	}
+	c.Set(middleware.ImageBytesContextKey, len(prepared.Bytes))
+
+	contentHash := storage.ContentHash(prepared.Bytes)
+	if existing, err := analysisStore.FindByContentHash(c.Request.Context(), contentHash, dedupWindow); err == nil {
+		var analysis AnalysisResponse
+		if jsonErr := json.Unmarshal(existing.Analysis, &analysis); jsonErr == nil {
+			c.JSON(http.StatusOK, analyzeResponse{AnalysisResponse: &analysis, ID: existing.ID})
+			return
+		}
+	}
+
+	analyzer, modelName, err := resolveAnalyzer(c)
+	if err != nil {
+		log.Printf("Error resolving analyzer: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Set(middleware.ModelContextKey, modelName)
+	analysis, usage, err := analyzer.Analyze(c.Request.Context(), prepared.Bytes, "image/jpeg")
 	if err != nil {
-		log.Printf("Error analyzing image: %v", err)
+		log.Printf("Error analyzing image with model %s: %v", modelName, err)
+		metrics.RecordError("bedrock")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to analyze image"})
 		return
 	}
 
+	metrics.RecordAnalysis(modelName)
+	if usage != nil {
+		metrics.RecordTokens(modelName, usage.InputTokens, usage.OutputTokens)
+		c.Set(middleware.InputTokensContextKey, usage.InputTokens)
+		c.Set(middleware.OutputTokensContextKey, usage.OutputTokens)
+	}
+
+	id, err := persistAnalysis(c.Request.Context(), contentHash, modelName, analysis, prepared.Bytes)
+	if err != nil {
+		log.Printf("Error persisting analysis: %v", err)
+	}
+
 	// Return analysis
-	c.JSON(http.StatusOK, analysis)
+	c.JSON(http.StatusOK, analyzeResponse{AnalysisResponse: analysis, ID: id})
+}
+
+// persistAnalysis stores analysis alongside its normalized image and returns the ID it was
+// stored under. A storage failure doesn't fail the request - the caller still gets their
+// analysis, just without a retrievable ID.
+func persistAnalysis(ctx context.Context, contentHash, model string, analysis *AnalysisResponse, imageBytes []byte) (string, error) {
+	id, err := storage.NewID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate analysis id: %w", err)
+	}
+
+	analysisJSON, err := json.Marshal(analysis)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal analysis: %w", err)
+	}
+
+	rec := &storage.Record{
+		ID:          id,
+		ContentHash: contentHash,
+		ImageKey:    fmt.Sprintf("analyses/%s.jpg", contentHash),
+		Model:       model,
+		Analysis:    analysisJSON,
+		CreatedAt:   time.Now(),
+	}
+
+	if err := analysisStore.Put(ctx, rec, imageBytes); err != nil {
+		return id, fmt.Errorf("failed to store analysis: %w", err)
+	}
+	return id, nil
+}
+
+// analyzeImageStreamHandler is the SSE counterpart of analyzeImageHandler: instead of
+// waiting for the full Bedrock response, it relays the model output to the browser as
+// it arrives so the UI can show the score first and fill in each section as it completes.
+func analyzeImageStreamHandler(c *gin.Context) {
+	var analysisReq AnalysisRequest
+	if err := c.ShouldBindJSON(&analysisReq); err != nil {
+		log.Printf("Error parsing request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if analysisReq.Image == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Image data is required"})
+		return
+	}
+
+	imageData := analysisReq.Image
+	if strings.Contains(imageData, ",") {
+		parts := strings.Split(imageData, ",")
+		if len(parts) > 1 {
+			imageData = parts[1]
+		}
+	}
+
+	rawBytes, err := base64.StdEncoding.DecodeString(imageData)
+	if err != nil {
+		log.Printf("Error decoding base64 image: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image data"})
+		return
+	}
+
+	prepared, err := imageprep.Process(rawBytes)
+	if err != nil {
+		if errors.Is(err, imageprep.ErrTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error preparing image: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or unsupported image"})
+		return
+	}
+	c.Set(middleware.ImageBytesContextKey, len(prepared.Bytes))
+
+	analyzer, modelName, err := resolveAnalyzer(c)
+	if err != nil {
+		log.Printf("Error resolving analyzer: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	streamer, ok := analyzer.(StreamingAnalyzer)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("model %q does not support streaming", modelName)})
+		return
+	}
+	c.Set(middleware.ModelContextKey, modelName)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	usage, err := streamer.AnalyzeStream(c.Request.Context(), prepared.Bytes, c.Writer)
+	if usage != nil {
+		c.Set(middleware.InputTokensContextKey, usage.InputTokens)
+		c.Set(middleware.OutputTokensContextKey, usage.OutputTokens)
+	}
+	if err != nil {
+		log.Printf("Error streaming analysis with model %s: %v", modelName, err)
+		writeSSEEvent(c.Writer, "error", gin.H{"error": "Failed to analyze image"})
+	}
+	c.Writer.Flush()
 }
 
-func analyzeImageWithBedrock(ctx context.Context, imageData string) (*AnalysisResponse, error) {
-	// Create the prompt for photography analysis (beginner-friendly)
-	prompt := `You are a friendly photography teacher helping someone who is new to film photography (they use a reel camera, not a smartphone).
+// photographyAnalysisPrompt is the prompt for photography analysis (beginner-friendly),
+// shared by the blocking and streaming Bedrock code paths.
+const photographyAnalysisPrompt = `You are a friendly photography teacher helping someone who is new to film photography (they use a reel camera, not a smartphone).
 They’ve shared a photo, and your job is to gently guide them with clear, simple feedback to help them improve.
 
 First, try to understand what the user was trying to capture in the photo (their intent). Was it a mood, a story, a main subject, or just something interesting? Use that to make your advice more helpful.
@@ -128,65 +308,10 @@ Focus suggestions on basic, easy-to-try ideas like:
 
 They’re learning with a film camera, so keep it practical and low-tech.`
 
-	// Decode base64 image data
-	imageBytes, err := base64.StdEncoding.DecodeString(imageData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode image data: %w", err)
-	}
-
-	// Create user message with image and text
-	userMessage := types.Message{
-		Role: types.ConversationRoleUser,
-		Content: []types.ContentBlock{
-			&types.ContentBlockMemberImage{
-				Value: types.ImageBlock{
-					Format: types.ImageFormatJpeg,
-					Source: &types.ImageSourceMemberBytes{
-						Value: imageBytes,
-					},
-				},
-			},
-			&types.ContentBlockMemberText{
-				Value: prompt,
-			},
-		},
-	}
-
-	// Call Bedrock Converse API
-	input := &bedrockruntime.ConverseInput{
-		ModelId:  aws.String("us.meta.llama4-scout-17b-instruct-v1:0"),
-		Messages: []types.Message{userMessage},
-		InferenceConfig: &types.InferenceConfiguration{
-			MaxTokens:   aws.Int32(2000),  // Reduced from 2000 to 800 for cost savings
-			Temperature: aws.Float32(0.7), // Adjusted for more creative responses
-			TopP:        aws.Float32(0.9), // Adjusted for more creative
-		},
-	}
-
-	result, err := bedrockClient.Converse(ctx, input)
-	if err != nil {
-		return nil, fmt.Errorf("failed to call bedrock converse: %w", err)
-	}
-
-	// Extract response text
-	if result.Output == nil {
-		return nil, fmt.Errorf("no output in bedrock response")
-	}
-
-	var responseText string
-	if msgResult, ok := result.Output.(*types.ConverseOutputMemberMessage); ok {
-		if len(msgResult.Value.Content) > 0 {
-			if textBlock, ok := msgResult.Value.Content[0].(*types.ContentBlockMemberText); ok {
-				responseText = textBlock.Value
-			}
-		}
-	}
-
-	if responseText == "" {
-		return nil, fmt.Errorf("no text content in bedrock response")
-	}
-
-	// Find JSON in the response (Claude sometimes adds extra text)
+// parseAnalysisJSON finds the JSON object in a Bedrock text response (the model sometimes
+// adds extra prose around it), unmarshals it into an AnalysisResponse, and clamps the score
+// to the 1-10 range. Shared by the blocking and streaming Bedrock code paths.
+func parseAnalysisJSON(responseText string) (*AnalysisResponse, error) {
 	jsonStart := strings.Index(responseText, "{")
 	jsonEnd := strings.LastIndex(responseText, "}") + 1
 
@@ -196,7 +321,6 @@ They’re learning with a film camera, so keep it practical and low-tech.`
 
 	jsonText := responseText[jsonStart:jsonEnd]
 
-	// Parse the analysis JSON
 	var analysis AnalysisResponse
 	if err := json.Unmarshal([]byte(jsonText), &analysis); err != nil {
 		log.Printf("Failed to parse JSON response: %s", jsonText)
@@ -213,6 +337,92 @@ They’re learning with a film camera, so keep it practical and low-tech.`
 	return &analysis, nil
 }
 
+// emitCompletedFields attempts to parse buffered as a (possibly truncated) JSON object and
+// emits an SSE event for each AnalysisResponse field that has a complete value and hasn't
+// been emitted yet. Truncated JSON is closed heuristically by counting unmatched braces,
+// brackets and quotes, so a field can be reported a little before the full object parses.
+func emitCompletedFields(buffered string, emitted map[string]bool, w http.ResponseWriter) {
+	start := strings.Index(buffered, "{")
+	if start == -1 {
+		return
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(closeDanglingJSON(buffered[start:])), &fields); err != nil {
+		return
+	}
+
+	for _, name := range []string{"score", "intent", "composition", "lighting", "subject", "strengths", "suggestions"} {
+		raw, ok := fields[name]
+		if !ok || emitted[name] {
+			continue
+		}
+
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			continue
+		}
+
+		emitted[name] = true
+		writeSSEEvent(w, name, value)
+	}
+}
+
+// closeDanglingJSON appends the closing characters needed to make a truncated JSON object
+// parseable: an unterminated string is closed first, then any open arrays/objects in the
+// order they were opened. It does not understand escaped quotes, so it only needs to get
+// close enough for encoding/json to recover the fields that are already complete.
+func closeDanglingJSON(s string) string {
+	var stack []byte
+	inString := false
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			if i == 0 || s[i-1] != '\\' {
+				inString = !inString
+			}
+		case '{', '[':
+			if !inString {
+				stack = append(stack, s[i])
+			}
+		case '}', ']':
+			if !inString && len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	var closer strings.Builder
+	if inString {
+		closer.WriteByte('"')
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] == '{' {
+			closer.WriteByte('}')
+		} else {
+			closer.WriteByte(']')
+		}
+	}
+
+	return s + closer.String()
+}
+
+// writeSSEEvent writes a single named SSE event with a JSON-encoded payload and flushes
+// it immediately so the browser receives it without buffering delay.
+func writeSSEEvent(w http.ResponseWriter, event string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error marshaling SSE payload for event %s: %v", event, err)
+		return
+	}
+
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 func main() {
 	// Set Gin mode from environment variable
 	if os.Getenv("GIN_MODE") == "" {
@@ -234,8 +444,30 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 	})
 
+	// Prometheus metrics
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	analyze := r.Group("/")
+	analyze.Use(
+		middleware.RequestLogger(),
+		// Rate limit by IP before auth runs, so a flood of invalid API keys is throttled
+		// instead of hitting the KeyStore (an unthrottled DynamoDB GetItem per bad guess)
+		// on every request.
+		newRateLimiter().Middleware(),
+		middleware.AuthMiddleware(newKeyStore()),
+		middleware.QuotaMiddleware(newQuotaCounter(), dailyQuota()),
+	)
+
 	// Photography analysis endpoint
-	r.POST("/analyze", analyzeImageHandler)
+	analyze.POST("/analyze", analyzeImageHandler)
+
+	// Streaming photography analysis endpoint (SSE)
+	analyze.POST("/analyze/stream", analyzeImageStreamHandler)
+
+	// Analysis history - behind the same auth/rate-limit/quota chain as /analyze, since a
+	// stored analysis includes a presigned URL to the uploaded photo.
+	analyze.GET("/analyses", listAnalysesHandler)
+	analyze.GET("/analyses/:id", getAnalysisHandler)
 
 	// Get port from environment variable or default to 8080
 	port := os.Getenv("PORT")
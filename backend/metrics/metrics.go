@@ -0,0 +1,54 @@
+// Package metrics exposes the Prometheus counters /metrics serves: analysis volume, errors
+// by type, and per-model token usage, so the cost of running this service is observable.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	analysesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "photography_assistant_analyses_total",
+		Help: "Total number of photo analyses completed, by model.",
+	}, []string{"model"})
+
+	errorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "photography_assistant_errors_total",
+		Help: "Total number of analyze request errors, by type.",
+	}, []string{"type"})
+
+	tokensTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "photography_assistant_tokens_total",
+		Help: "Total estimated Bedrock tokens consumed, by model and direction (input/output).",
+	}, []string{"model", "direction"})
+)
+
+// RecordAnalysis increments the completed-analysis counter for model.
+func RecordAnalysis(model string) {
+	analysesTotal.WithLabelValues(model).Inc()
+}
+
+// RecordError increments the error counter for errType (e.g. "invalid_image", "bedrock",
+// "image_too_large").
+func RecordError(errType string) {
+	errorsTotal.WithLabelValues(errType).Inc()
+}
+
+// RecordTokens adds inputTokens and outputTokens to the running total for model.
+func RecordTokens(model string, inputTokens, outputTokens int) {
+	if inputTokens > 0 {
+		tokensTotal.WithLabelValues(model, "input").Add(float64(inputTokens))
+	}
+	if outputTokens > 0 {
+		tokensTotal.WithLabelValues(model, "output").Add(float64(outputTokens))
+	}
+}
+
+// Handler serves the current metrics in the Prometheus text exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,99 @@
+// Package middleware provides the auth, rate limiting and quota layers that sit in front
+// of the Bedrock-backed endpoints, since those calls cost real money and the service
+// previously had no access control at all.
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyContextKey is the gin context key AuthMiddleware stores the caller's API key
+// under, so middleware that runs after it (quotas) can key off it.
+const APIKeyContextKey = "apiKey"
+
+// KeyStore validates an API key presented via the Authorization header.
+type KeyStore interface {
+	IsValid(ctx context.Context, key string) (bool, error)
+}
+
+// EnvKeyStore validates against a fixed set of keys, typically loaded from an
+// environment variable at startup.
+type EnvKeyStore struct {
+	keys map[string]struct{}
+}
+
+// NewEnvKeyStore builds an EnvKeyStore from a list of valid keys.
+func NewEnvKeyStore(keys []string) *EnvKeyStore {
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		if k = strings.TrimSpace(k); k != "" {
+			set[k] = struct{}{}
+		}
+	}
+	return &EnvKeyStore{keys: set}
+}
+
+func (s *EnvKeyStore) IsValid(ctx context.Context, key string) (bool, error) {
+	_, ok := s.keys[key]
+	return ok, nil
+}
+
+// DynamoKeyStore validates API keys against a DynamoDB table keyed by "apiKey", so keys
+// can be issued and revoked without a redeploy.
+type DynamoKeyStore struct {
+	ddb   *dynamodb.Client
+	table string
+}
+
+// NewDynamoKeyStore builds a DynamoKeyStore backed by the given table.
+func NewDynamoKeyStore(cfg aws.Config, table string) *DynamoKeyStore {
+	return &DynamoKeyStore{ddb: dynamodb.NewFromConfig(cfg), table: table}
+}
+
+func (s *DynamoKeyStore) IsValid(ctx context.Context, key string) (bool, error) {
+	out, err := s.ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.table),
+		Key: map[string]types.AttributeValue{
+			"apiKey": &types.AttributeValueMemberS{Value: key},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	return out.Item != nil, nil
+}
+
+// AuthMiddleware rejects requests without a valid "Authorization: Bearer <key>" header,
+// and stores the key in the gin context for any middleware after it (such as the quota
+// middleware) to key off.
+func AuthMiddleware(store KeyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		const prefix = "Bearer "
+		if !strings.HasPrefix(header, prefix) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing or malformed Authorization header"})
+			return
+		}
+
+		key := strings.TrimPrefix(header, prefix)
+		valid, err := store.IsValid(c.Request.Context(), key)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate API key"})
+			return
+		}
+		if !valid {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+			return
+		}
+
+		c.Set(APIKeyContextKey, key)
+		c.Next()
+	}
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Context keys the analyze handlers set via c.Set so RequestLogger can report what a
+// request actually cost, alongside APIKeyContextKey in auth.go.
+const (
+	ModelContextKey        = "model"
+	ImageBytesContextKey   = "imageBytes"
+	InputTokensContextKey  = "inputTokens"
+	OutputTokensContextKey = "outputTokens"
+)
+
+// requestLogEntry is the structured line RequestLogger emits per request.
+type requestLogEntry struct {
+	Method       string `json:"method"`
+	Path         string `json:"path"`
+	Status       int    `json:"status"`
+	LatencyMS    int64  `json:"latencyMs"`
+	ClientIP     string `json:"clientIp"`
+	APIKey       string `json:"apiKey,omitempty"`
+	Model        string `json:"model,omitempty"`
+	ImageBytes   int    `json:"imageBytes,omitempty"`
+	InputTokens  int    `json:"inputTokens,omitempty"`
+	OutputTokens int    `json:"outputTokens,omitempty"`
+	RequestErr   string `json:"error,omitempty"`
+}
+
+// RequestLogger logs one JSON line per request with latency and the caller's identity, so
+// request volume and slow endpoints are visible without grepping gin's default text log.
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+
+		entry := requestLogEntry{
+			Method:    c.Request.Method,
+			Path:      c.FullPath(),
+			Status:    c.Writer.Status(),
+			LatencyMS: latency.Milliseconds(),
+			ClientIP:  c.ClientIP(),
+		}
+		if key, ok := c.Get(APIKeyContextKey); ok {
+			entry.APIKey = redactKey(key.(string))
+		}
+		if model, ok := c.Get(ModelContextKey); ok {
+			entry.Model = model.(string)
+		}
+		if size, ok := c.Get(ImageBytesContextKey); ok {
+			entry.ImageBytes = size.(int)
+		}
+		if tokens, ok := c.Get(InputTokensContextKey); ok {
+			entry.InputTokens = tokens.(int)
+		}
+		if tokens, ok := c.Get(OutputTokensContextKey); ok {
+			entry.OutputTokens = tokens.(int)
+		}
+		if len(c.Errors) > 0 {
+			entry.RequestErr = c.Errors.String()
+		}
+
+		line, err := json.Marshal(entry)
+		if err != nil {
+			log.Printf("failed to marshal request log entry: %v", err)
+			return
+		}
+		log.Println(string(line))
+	}
+}
+
+// redactKey keeps only the last 4 characters of an API key so logs don't retain full
+// credentials.
+func redactKey(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return "****" + key[len(key)-4:]
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// QuotaCounter tracks how many requests a key has made on a given UTC day and enforces a
+// daily limit.
+type QuotaCounter interface {
+	// Increment records one more request for key on day (an UTC "2006-01-02" date string)
+	// and returns the count after incrementing.
+	Increment(ctx context.Context, key, day string) (int64, error)
+}
+
+// MemoryQuotaCounter is an in-process QuotaCounter for local development and tests.
+type MemoryQuotaCounter struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// NewMemoryQuotaCounter returns an empty MemoryQuotaCounter.
+func NewMemoryQuotaCounter() *MemoryQuotaCounter {
+	return &MemoryQuotaCounter{counts: make(map[string]int64)}
+}
+
+func (m *MemoryQuotaCounter) Increment(ctx context.Context, key, day string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bucket := key + ":" + day
+	m.counts[bucket]++
+	return m.counts[bucket], nil
+}
+
+// RedisQuotaCounter tracks quotas in Redis so counts are shared across instances and expire
+// on their own 48 hours after the day's first request.
+type RedisQuotaCounter struct {
+	client *redis.Client
+}
+
+// NewRedisQuotaCounter builds a RedisQuotaCounter against the given client.
+func NewRedisQuotaCounter(client *redis.Client) *RedisQuotaCounter {
+	return &RedisQuotaCounter{client: client}
+}
+
+func (r *RedisQuotaCounter) Increment(ctx context.Context, key, day string) (int64, error) {
+	bucket := fmt.Sprintf("quota:%s:%s", key, day)
+
+	count, err := r.client.Incr(ctx, bucket).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment quota counter: %w", err)
+	}
+	if count == 1 {
+		r.client.Expire(ctx, bucket, 48*time.Hour)
+	}
+	return count, nil
+}
+
+// QuotaMiddleware enforces dailyLimit requests per key (API key if AuthMiddleware ran, client IP
+// otherwise), rejecting the request with 429 once the key's count for the day is exceeded.
+func QuotaMiddleware(counter QuotaCounter, dailyLimit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := rateLimitKey(c)
+		day := time.Now().UTC().Format("2006-01-02")
+
+		count, err := counter.Increment(c.Request.Context(), key, day)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check quota"})
+			return
+		}
+
+		if count > dailyLimit {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Daily quota exceeded"})
+			return
+		}
+
+		c.Next()
+	}
+}
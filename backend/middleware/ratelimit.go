@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter hands out a token-bucket limiter per key (API key if present, client IP
+// otherwise), so one noisy caller can't starve the others.
+type RateLimiter struct {
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+	rps      rate.Limit
+	burst    int
+}
+
+// NewRateLimiter builds a RateLimiter allowing rps requests per second per key, with bursts
+// of up to burst requests absorbed instantaneously.
+func NewRateLimiter(rps float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		limiters: make(map[string]*rate.Limiter),
+		rps:      rate.Limit(rps),
+		burst:    burst,
+	}
+}
+
+func (r *RateLimiter) limiterFor(key string) *rate.Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	limiter, ok := r.limiters[key]
+	if !ok {
+		limiter = rate.NewLimiter(r.rps, r.burst)
+		r.limiters[key] = limiter
+	}
+	return limiter
+}
+
+// Middleware rejects a request with 429 once its key's bucket is exhausted.
+func (r *RateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := rateLimitKey(c)
+		if !r.limiterFor(key).Allow() {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimitKey keys the limiter by API key when AuthMiddleware has run, falling back to the
+// client IP so the limiter still works on routes without auth.
+func rateLimitKey(c *gin.Context) string {
+	if key, ok := c.Get(APIKeyContextKey); ok {
+		return key.(string)
+	}
+	return c.ClientIP()
+}
@@ -0,0 +1,273 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// presignExpiry is how long a GET URL returned by ImageURL stays valid.
+const presignExpiry = 15 * time.Minute
+
+// contentHashIndex is the name of the DynamoDB GSI that FindByContentHash queries. It must
+// be created on the table with contentHash as its partition key.
+const contentHashIndex = "contentHash-index"
+
+// createdAtIndex is the name of the DynamoDB GSI that List queries to return records
+// newest-first, matching MemoryStore's ordering. It must be created on the table with
+// listPk (a constant value, every item has the same one) as its partition key and createdAt
+// as its sort key - DynamoDB GSIs always need a partition key, and there's no natural one
+// for "every record in the table", so every item is written into the same partition here.
+const createdAtIndex = "createdAt-index"
+
+// listPartitionKey is the fixed value every item's listPk attribute is set to, so
+// createdAtIndex has a single partition to query in sorted order.
+const listPartitionKey = "analysis"
+
+// DynamoS3Store is the production Store: analyses live in DynamoDB, images in S3, addressed
+// by the SHA-256 of the normalized image bytes.
+type DynamoS3Store struct {
+	ddb    *dynamodb.Client
+	s3     *s3.Client
+	presig *s3.PresignClient
+	table  string
+	bucket string
+}
+
+// NewDynamoS3Store builds a Store backed by the given DynamoDB table and S3 bucket.
+func NewDynamoS3Store(cfg aws.Config, table, bucket string) *DynamoS3Store {
+	s3Client := s3.NewFromConfig(cfg)
+	return &DynamoS3Store{
+		ddb:    dynamodb.NewFromConfig(cfg),
+		s3:     s3Client,
+		presig: s3.NewPresignClient(s3Client),
+		table:  table,
+		bucket: bucket,
+	}
+}
+
+func (d *DynamoS3Store) Put(ctx context.Context, rec *Record, imageBytes []byte) error {
+	if _, err := d.s3.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(d.bucket),
+		Key:         aws.String(rec.ImageKey),
+		Body:        bytes.NewReader(imageBytes),
+		ContentType: aws.String("image/jpeg"),
+	}); err != nil {
+		return fmt.Errorf("failed to upload image to s3: %w", err)
+	}
+
+	item, err := attributevalue.MarshalMap(dynamoRecord{
+		ID:          rec.ID,
+		ContentHash: rec.ContentHash,
+		ImageKey:    rec.ImageKey,
+		Model:       rec.Model,
+		Analysis:    string(rec.Analysis),
+		CreatedAt:   rec.CreatedAt.Unix(),
+		ListPk:      listPartitionKey,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	if _, err := d.ddb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(d.table),
+		Item:      item,
+	}); err != nil {
+		return fmt.Errorf("failed to put record in dynamodb: %w", err)
+	}
+	return nil
+}
+
+func (d *DynamoS3Store) Get(ctx context.Context, id string) (*Record, error) {
+	out, err := d.ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(d.table),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get record from dynamodb: %w", err)
+	}
+	if out.Item == nil {
+		return nil, ErrNotFound
+	}
+
+	return unmarshalRecord(out.Item)
+}
+
+func (d *DynamoS3Store) ImageURL(ctx context.Context, rec *Record) (string, error) {
+	presigned, err := d.presig.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(d.bucket),
+		Key:    aws.String(rec.ImageKey),
+	}, s3.WithPresignExpires(presignExpiry))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign image url: %w", err)
+	}
+	return presigned.URL, nil
+}
+
+func (d *DynamoS3Store) FindByContentHash(ctx context.Context, hash string, ttl time.Duration) (*Record, error) {
+	out, err := d.ddb.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(d.table),
+		IndexName:              aws.String(contentHashIndex),
+		KeyConditionExpression: aws.String("contentHash = :h"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":h": &types.AttributeValueMemberS{Value: hash},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int32(1),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query content hash index: %w", err)
+	}
+	if len(out.Items) == 0 {
+		return nil, ErrNotFound
+	}
+
+	rec, err := unmarshalRecord(out.Items[0])
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(rec.CreatedAt) > ttl {
+		return nil, ErrNotFound
+	}
+	return rec, nil
+}
+
+func (d *DynamoS3Store) List(ctx context.Context, limit int, cursor string) (*Page, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(d.table),
+		IndexName:              aws.String(createdAtIndex),
+		KeyConditionExpression: aws.String("listPk = :p"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":p": &types.AttributeValueMemberS{Value: listPartitionKey},
+		},
+		ScanIndexForward: aws.Bool(false), // newest first, matching MemoryStore.List
+		Limit:            aws.Int32(int32(limit)),
+	}
+
+	if cursor != "" {
+		startKey, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		input.ExclusiveStartKey = startKey
+	}
+
+	out, err := d.ddb.Query(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query createdAt index: %w", err)
+	}
+
+	page := &Page{}
+	for _, item := range out.Items {
+		rec, err := unmarshalRecord(item)
+		if err != nil {
+			return nil, err
+		}
+		page.Records = append(page.Records, rec)
+	}
+
+	if len(out.LastEvaluatedKey) > 0 {
+		next, err := encodeCursor(out.LastEvaluatedKey)
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = next
+	}
+	return page, nil
+}
+
+// dynamoRecord is the DynamoDB item shape for a Record. Analysis is stored as a JSON
+// string rather than a nested map so the table doesn't need to track the shape of
+// AnalysisResponse.
+type dynamoRecord struct {
+	ID          string `dynamodbav:"id"`
+	ContentHash string `dynamodbav:"contentHash"`
+	ImageKey    string `dynamodbav:"imageKey"`
+	Model       string `dynamodbav:"model"`
+	Analysis    string `dynamodbav:"analysis"`
+	CreatedAt   int64  `dynamodbav:"createdAt"`
+	ListPk      string `dynamodbav:"listPk"`
+}
+
+func unmarshalRecord(item map[string]types.AttributeValue) (*Record, error) {
+	var dr dynamoRecord
+	if err := attributevalue.UnmarshalMap(item, &dr); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal record: %w", err)
+	}
+
+	return &Record{
+		ID:          dr.ID,
+		ContentHash: dr.ContentHash,
+		ImageKey:    dr.ImageKey,
+		Model:       dr.Model,
+		Analysis:    json.RawMessage(dr.Analysis),
+		CreatedAt:   time.Unix(dr.CreatedAt, 0).UTC(),
+	}, nil
+}
+
+// cursorAttr is the JSON-friendly form of a single DynamoDB AttributeValue that encodeCursor
+// preserves the type of - exactly one of S or N is set, matching the attribute types List's
+// keys actually use (the "id" partition key is a string, createdAtIndex's sort key is a
+// number).
+type cursorAttr struct {
+	S *string `json:"s,omitempty"`
+	N *string `json:"n,omitempty"`
+}
+
+// encodeCursor/decodeCursor turn a DynamoDB LastEvaluatedKey into the opaque string cursor
+// the GET /analyses API exposes, so pagination state never leaks DynamoDB's key shape.
+func encodeCursor(key map[string]types.AttributeValue) (string, error) {
+	simple := make(map[string]cursorAttr, len(key))
+	for k, v := range key {
+		switch av := v.(type) {
+		case *types.AttributeValueMemberS:
+			simple[k] = cursorAttr{S: aws.String(av.Value)}
+		case *types.AttributeValueMemberN:
+			simple[k] = cursorAttr{N: aws.String(av.Value)}
+		default:
+			return "", fmt.Errorf("unsupported cursor attribute type %T for key %q", v, k)
+		}
+	}
+
+	raw, err := json.Marshal(simple)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(cursor string) (map[string]types.AttributeValue, error) {
+	raw, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	var simple map[string]cursorAttr
+	if err := json.Unmarshal(raw, &simple); err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	key := make(map[string]types.AttributeValue, len(simple))
+	for k, v := range simple {
+		switch {
+		case v.S != nil:
+			key[k] = &types.AttributeValueMemberS{Value: *v.S}
+		case v.N != nil:
+			key[k] = &types.AttributeValueMemberN{Value: *v.N}
+		default:
+			return nil, fmt.Errorf("invalid cursor: key %q has no attribute value", k)
+		}
+	}
+	return key, nil
+}
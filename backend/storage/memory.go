@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process Store used by tests and local development so neither needs
+// real AWS credentials.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records map[string]*Record
+	images  map[string][]byte
+	byHash  map[string]string // content hash -> record ID
+	order   []string          // record IDs in insertion order, newest last
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: make(map[string]*Record),
+		images:  make(map[string][]byte),
+		byHash:  make(map[string]string),
+	}
+}
+
+func (m *MemoryStore) Put(ctx context.Context, rec *Record, imageBytes []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records[rec.ID] = rec
+	m.images[rec.ID] = imageBytes
+	m.byHash[rec.ContentHash] = rec.ID
+	m.order = append(m.order, rec.ID)
+	return nil
+}
+
+func (m *MemoryStore) Get(ctx context.Context, id string) (*Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rec, ok := m.records[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return rec, nil
+}
+
+// ImageURL returns a fake local reference for the in-memory store; there's no real object
+// store behind it, so the URL is a memory:// reference to rec.ImageKey that can't be fetched.
+func (m *MemoryStore) ImageURL(ctx context.Context, rec *Record) (string, error) {
+	return fmt.Sprintf("memory://%s", rec.ImageKey), nil
+}
+
+func (m *MemoryStore) FindByContentHash(ctx context.Context, hash string, ttl time.Duration) (*Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.byHash[hash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	rec := m.records[id]
+	if time.Since(rec.CreatedAt) > ttl {
+		return nil, ErrNotFound
+	}
+	return rec, nil
+}
+
+func (m *MemoryStore) List(ctx context.Context, limit int, cursor string) (*Page, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	start := 0
+	if cursor != "" {
+		parsed, err := strconv.Atoi(cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor: %w", err)
+		}
+		start = parsed
+	}
+
+	// Newest first.
+	ids := make([]string, len(m.order))
+	copy(ids, m.order)
+	for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+		ids[i], ids[j] = ids[j], ids[i]
+	}
+
+	if start >= len(ids) {
+		return &Page{}, nil
+	}
+
+	end := start + limit
+	if limit <= 0 || end > len(ids) {
+		end = len(ids)
+	}
+
+	page := &Page{}
+	for _, id := range ids[start:end] {
+		page.Records = append(page.Records, m.records[id])
+	}
+	if end < len(ids) {
+		page.NextCursor = strconv.Itoa(end)
+	}
+	return page, nil
+}
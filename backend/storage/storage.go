@@ -0,0 +1,66 @@
+// Package storage persists analyses so they can be retrieved after the request that
+// created them — the basis for a frontend gallery instead of a one-shot response. A Store
+// is backed by S3 (the image) and DynamoDB (the analysis + metadata) in production, or
+// kept in memory for tests.
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when a lookup by ID finds no record.
+var ErrNotFound = errors.New("analysis not found")
+
+// Record is one stored analysis: the model output plus enough metadata to show it in a
+// gallery and to dedup future uploads of the same image.
+type Record struct {
+	ID          string          `json:"id"`
+	ContentHash string          `json:"contentHash"`
+	ImageKey    string          `json:"imageKey"`
+	Model       string          `json:"model"`
+	Analysis    json.RawMessage `json:"analysis"`
+	CreatedAt   time.Time       `json:"createdAt"`
+}
+
+// Page is one page of a List call: the records plus an opaque cursor for the next page,
+// empty when there isn't one.
+type Page struct {
+	Records    []*Record
+	NextCursor string
+}
+
+// Store is implemented by every storage backend. Analyses are content-addressed in S3 by
+// ContentHash so identical uploads dedup for free at the blob layer; FindByContentHash lets
+// the handler dedup the (expensive) analysis step itself.
+type Store interface {
+	Put(ctx context.Context, rec *Record, imageBytes []byte) error
+	Get(ctx context.Context, id string) (*Record, error)
+	// ImageURL returns a URL the caller can use to fetch the stored image for rec,
+	// presigned with a short expiry for the S3-backed store.
+	ImageURL(ctx context.Context, rec *Record) (string, error)
+	FindByContentHash(ctx context.Context, hash string, ttl time.Duration) (*Record, error)
+	// List returns records newest-first, so every Store implementation must agree on that
+	// order - the gallery UI pages through List assuming it.
+	List(ctx context.Context, limit int, cursor string) (*Page, error)
+}
+
+// ContentHash returns the SHA-256 hex digest used to address and dedup an uploaded image.
+func ContentHash(imageBytes []byte) string {
+	sum := sha256.Sum256(imageBytes)
+	return hex.EncodeToString(sum[:])
+}
+
+// NewID generates the random, URL-safe identifier assigned to a new Record.
+func NewID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
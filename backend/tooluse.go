@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// recordAnalysisToolName is the name of the tool Bedrock is forced to call, replacing the
+// old approach of fishing a JSON object out of free-form text with strings.Index/LastIndex.
+const recordAnalysisToolName = "record_analysis"
+
+// recordAnalysisInput mirrors AnalysisResponse plus the intent field the prompt already
+// asks the model to infer; it's the shape record_analysis's input schema describes and the
+// shape toolUse.input is decoded into.
+type recordAnalysisInput struct {
+	Score       int      `json:"score"`
+	Intent      string   `json:"intent"`
+	Composition string   `json:"composition"`
+	Lighting    string   `json:"lighting"`
+	Subject     string   `json:"subject"`
+	Strengths   []string `json:"strengths"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// recordAnalysisDescription is the tool description shared by every provider's tool
+// definition.
+const recordAnalysisDescription = "Record the structured photography analysis for the submitted photo."
+
+// recordAnalysisInputSchema is the JSON schema for recordAnalysisInput, shared by every
+// provider's tool definition so the providers' schemas can't drift apart. It is written out
+// by hand, not derived from the Go struct it decodes into.
+func recordAnalysisInputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"score":       map[string]interface{}{"type": "integer", "description": "Score from 1 to 10"},
+			"intent":      map[string]interface{}{"type": "string", "description": "Guess at what the photographer wanted to capture"},
+			"composition": map[string]interface{}{"type": "string"},
+			"lighting":    map[string]interface{}{"type": "string"},
+			"subject":     map[string]interface{}{"type": "string"},
+			"strengths": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "string"},
+			},
+			"suggestions": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "string"},
+			},
+		},
+		"required": []string{"score", "intent", "composition", "lighting", "subject", "strengths", "suggestions"},
+	}
+}
+
+// recordAnalysisToolConfig builds the ToolConfig that forces the model to call
+// record_analysis instead of replying with free-form text, so the response is always
+// well-formed JSON matching recordAnalysisInput.
+func recordAnalysisToolConfig() *types.ToolConfiguration {
+	return &types.ToolConfiguration{
+		Tools: []types.Tool{
+			&types.ToolMemberToolSpec{
+				Value: types.ToolSpecification{
+					Name:        aws.String(recordAnalysisToolName),
+					Description: aws.String(recordAnalysisDescription),
+					InputSchema: &types.ToolInputSchemaMemberJson{
+						Value: document.NewLazyDocument(recordAnalysisInputSchema()),
+					},
+				},
+			},
+		},
+		ToolChoice: &types.ToolChoiceMemberTool{
+			Value: types.SpecificToolChoice{
+				Name: aws.String(recordAnalysisToolName),
+			},
+		},
+	}
+}
+
+// analysisFromToolUse finds the record_analysis tool call in content and decodes its input
+// into an AnalysisResponse, clamping the score the same way the text-parsing path always
+// has. It returns an error if no matching tool use block is present.
+func analysisFromToolUse(content []types.ContentBlock) (*AnalysisResponse, error) {
+	for _, block := range content {
+		toolUse, ok := block.(*types.ContentBlockMemberToolUse)
+		if !ok || toolUse.Value.Name == nil || *toolUse.Value.Name != recordAnalysisToolName {
+			continue
+		}
+
+		var input recordAnalysisInput
+		if err := toolUse.Value.Input.UnmarshalSmithyDocument(&input); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tool use input: %w", err)
+		}
+
+		return analysisFromRecordAnalysisInput(input), nil
+	}
+
+	return nil, fmt.Errorf("no record_analysis tool use found")
+}
+
+// analysisFromRecordAnalysisInput converts a decoded recordAnalysisInput into an
+// AnalysisResponse, clamping the score the same way the text-parsing path always has.
+// Shared by every provider's tool-use result, however each decodes the raw input into
+// recordAnalysisInput.
+func analysisFromRecordAnalysisInput(input recordAnalysisInput) *AnalysisResponse {
+	analysis := &AnalysisResponse{
+		Score:       input.Score,
+		Composition: input.Composition,
+		Lighting:    input.Lighting,
+		Subject:     input.Subject,
+		Strengths:   input.Strengths,
+		Suggestions: input.Suggestions,
+	}
+	if analysis.Score < 1 {
+		analysis.Score = 1
+	} else if analysis.Score > 10 {
+		analysis.Score = 10
+	}
+	return analysis
+}